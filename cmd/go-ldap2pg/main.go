@@ -1,14 +1,21 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"runtime"
 	"runtime/debug"
 
 	"golang.org/x/exp/slog"
+	"gopkg.in/yaml.v3"
 
+	"github.com/dalibo/ldap2pg/internal/config"
+	"github.com/dalibo/ldap2pg/internal/grants"
 	. "github.com/dalibo/ldap2pg/internal/ldap2pg" //nolint:revive
+	"github.com/dalibo/ldap2pg/internal/postgres"
+	"github.com/dalibo/ldap2pg/internal/roles"
+	"github.com/dalibo/ldap2pg/internal/sync"
 )
 
 func main() {
@@ -22,6 +29,25 @@ func main() {
 	}
 }
 
+// runConfigCheck validates and normalizes the configuration file at path
+// without a live Postgres or LDAP connection, printing the normal form on
+// success. It backs the CheckAction branch of run()'s Action dispatch:
+// `ldap2pg config check <file>` is recognized by Load() like any other
+// flag/argument combination, not hand-matched against os.Args.
+func runConfigCheck(path string) error {
+	normalized, err := config.CheckFile(path)
+	if err != nil {
+		return fmt.Errorf("invalid configuration: %w", err)
+	}
+
+	out, err := yaml.Marshal(normalized)
+	if err != nil {
+		return err
+	}
+	fmt.Print(string(out))
+	return nil
+}
+
 func run() (err error) {
 	err = SetupLogging()
 	if err != nil {
@@ -40,6 +66,8 @@ func run() (err error) {
 	case ShowVersionAction:
 		showVersion()
 		return
+	case CheckAction:
+		return runConfigCheck(config.ConfigFile)
 	case RunAction:
 	}
 
@@ -53,17 +81,62 @@ func run() (err error) {
 		"path", config.ConfigFile,
 		"version", config.Version)
 
-	_, err = PostgresInspect(config)
+	current, err := PostgresInspect(config)
+	if err != nil {
+		return
+	}
+
+	wanted, err := ComputeWanted(config)
+	if err != nil {
+		return
+	}
+
+	// Order roles so parents are created before children reference them in
+	// a GRANT ... TO. A membership cycle is a config error, not something
+	// the driver should discover mid-transaction.
+	membershipOrder, err := roles.TopoSortRoles(wanted.Roles)
 	if err != nil {
 		return
 	}
 
-	_, err = ComputeWanted(config)
+	databaseOrder := current.Databases.SyncOrder(config.Postgres.DefaultDatabase)
+
+	ch := make(chan postgres.SyncQuery)
+	go func() {
+		defer close(ch)
+		grants.Diff(current.Grants, wanted.Grants, ch)
+		roles.Diff(current.Roles, wanted.Roles, membershipOrder, ch)
+		// Membership must be reconciled, including REVOKEs of roles about to
+		// be dropped, before DropUnwanted runs: a DROP ROLE executed ahead
+		// of a REVOKE naming it as parent fails with "role does not exist"
+		// and aborts the whole transaction.
+		for _, name := range membershipOrder {
+			wantedRole, ok := wanted.Roles[name]
+			if !ok {
+				continue
+			}
+			currentRole := current.Roles[name]
+			currentRole.Name = name
+			currentRole.ReconcileMembership(wantedRole, ch)
+		}
+		roles.DropUnwanted(current.Roles, wanted.Roles, databaseOrder, ch)
+	}()
+
+	ctx := context.Background()
+	driver, err := sync.NewDriver(ctx, config.Postgres.DSN, current.Databases, config.Postgres.DefaultDatabase)
 	if err != nil {
 		return
 	}
+	defer driver.Close()
 
-	slog.Info("Doing nothing yet.")
+	summary, err := driver.Sync(ctx, ch, databaseOrder, config.Mode)
+	if err != nil {
+		if driftErr, ok := err.(*sync.DriftError); ok {
+			slog.Error("Instance drifted from wanted state.", "count", driftErr.Count)
+		}
+		return
+	}
+	slog.Info("Synchronization complete.", summary.LogArgs()...)
 	return
 }
 
@@ -0,0 +1,12 @@
+package config
+
+// CheckFile validates and normalizes the YAML configuration file at path,
+// without needing a live Postgres or LDAP connection. It backs the
+// `ldap2pg config check <file>` subcommand: CI pipelines can run it to
+// catch KeyConflict, ParseError and spurious-key mistakes before any real
+// synchronization is attempted. It is a thin wrapper over LoadFile, the
+// same loader a real run uses, so `config check` catches exactly what a
+// run would.
+func CheckFile(path string) (normalized map[string]interface{}, err error) {
+	return LoadFile(path)
+}
@@ -4,9 +4,11 @@ package config
 import (
 	"errors"
 	"fmt"
+	"regexp"
 	"strings"
 
 	"github.com/dalibo/ldap2pg/internal/ldap"
+	"github.com/dalibo/ldap2pg/internal/policy"
 	"golang.org/x/exp/maps"
 )
 
@@ -138,6 +140,7 @@ func NormalizeSyncItem(yaml interface{}) (item map[string]interface{}, err error
 		"ldapsearch":  map[string]interface{}{},
 		"roles":       []interface{}{},
 		"grants":      []interface{}{},
+		"when":        nil,
 	}
 
 	yamlMap, ok := yaml.(map[string]interface{})
@@ -170,6 +173,11 @@ func NormalizeSyncItem(yaml interface{}) (item map[string]interface{}, err error
 	}
 	item["ldapsearch"] = search
 
+	item["when"], err = NormalizeWhen(item["when"])
+	if err != nil {
+		return nil, fmt.Errorf("when: %w", err)
+	}
+
 	list := NormalizeList(item["roles"])
 	rules := []interface{}{}
 	for i, rawRule := range list {
@@ -178,13 +186,232 @@ func NormalizeSyncItem(yaml interface{}) (item map[string]interface{}, err error
 		if err != nil {
 			return nil, fmt.Errorf("roles[%d]: %w", i, err)
 		}
-		for _, rule := range DuplicateRoleRules(rule) {
+		var duplicated []map[string]interface{}
+		duplicated, err = DuplicateRoleRules(rule, nil)
+		if err != nil {
+			return nil, fmt.Errorf("roles[%d]: %w", i, err)
+		}
+		for _, rule := range duplicated {
 			rules = append(rules, rule)
 		}
 	}
 	item["roles"] = rules
 
-	err = CheckSpuriousKeys(&item, "description", "ldapsearch", "roles", "grants")
+	list = NormalizeList(item["grants"])
+	grants := []interface{}{}
+	for i, rawGrant := range list {
+		var rule map[string]interface{}
+		rule, err = NormalizeGrantRule(rawGrant)
+		if err != nil {
+			return nil, fmt.Errorf("grants[%d]: %w", i, err)
+		}
+		var duplicatedGrants []map[string]interface{}
+		duplicatedGrants, err = DuplicateGrantRules(rule, nil)
+		if err != nil {
+			return nil, fmt.Errorf("grants[%d]: %w", i, err)
+		}
+		for _, grant := range duplicatedGrants {
+			grants = append(grants, grant)
+		}
+	}
+	item["grants"] = grants
+
+	err = CheckSpuriousKeys(&item, "description", "ldapsearch", "roles", "grants", "when")
+	return
+}
+
+// NormalizeWhen normalizes a `when:` condition block into a map with
+// exactly one recognized operator key, recursing into and/or/not so the
+// whole tree can be walked later by internal/policy. A nil yaml means no
+// condition at all: the rule always applies.
+func NormalizeWhen(yaml interface{}) (when map[string]interface{}, err error) {
+	if yaml == nil {
+		return nil, nil
+	}
+
+	yamlMap, ok := yaml.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("bad type: %T, must be a map", yaml)
+	}
+	if len(yamlMap) != 1 {
+		return nil, fmt.Errorf("must have exactly one condition, got %d", len(yamlMap))
+	}
+
+	for key, value := range yamlMap {
+		switch key {
+		case "and", "or":
+			list := NormalizeList(value)
+			conditions := []interface{}{}
+			for i, rawCond := range list {
+				var cond map[string]interface{}
+				cond, err = NormalizeWhen(rawCond)
+				if err != nil {
+					return nil, fmt.Errorf("%s[%d]: %w", key, i, err)
+				}
+				conditions = append(conditions, cond)
+			}
+			return map[string]interface{}{key: conditions}, nil
+		case "not":
+			var cond map[string]interface{}
+			cond, err = NormalizeWhen(value)
+			if err != nil {
+				return nil, fmt.Errorf("not: %w", err)
+			}
+			return map[string]interface{}{"not": cond}, nil
+		case "ldap_attr_equals", "ldap_attr_matches":
+			attrMap, ok := value.(map[string]interface{})
+			if !ok || len(attrMap) != 1 {
+				return nil, fmt.Errorf("%s: must be a single attribute/value map", key)
+			}
+			if key == "ldap_attr_matches" {
+				for attr, pattern := range attrMap {
+					if _, err = regexp.Compile(fmt.Sprintf("%v", pattern)); err != nil {
+						return nil, fmt.Errorf("ldap_attr_matches: %s: %w", attr, err)
+					}
+				}
+			}
+			return map[string]interface{}{key: attrMap}, nil
+		case "dn_in_subtree", "member_of":
+			s, ok := value.(string)
+			if !ok {
+				return nil, fmt.Errorf("%s: must be a string", key)
+			}
+			return map[string]interface{}{key: s}, nil
+		default:
+			return nil, fmt.Errorf("unknown condition: %s", key)
+		}
+	}
+	return
+}
+
+// grantOnTypes maps the user-facing `on:` value to the SQL object type it
+// expands to.
+var grantOnTypes = map[string]string{
+	"schema":    "SCHEMA",
+	"tables":    "TABLE",
+	"sequences": "SEQUENCE",
+	"functions": "FUNCTION",
+}
+
+func NormalizeGrantRule(yaml interface{}) (rule map[string]interface{}, err error) {
+	rule = map[string]interface{}{
+		"privileges":        []string{},
+		"on":                "schema",
+		"schemas":           []string{},
+		"roles":             []string{},
+		"with_grant_option": false,
+		"owner":             "",
+		"when":              nil,
+	}
+
+	yamlMap, ok := yaml.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("bad type: %T, must be a map", yaml)
+	}
+
+	err = NormalizeAlias(&yamlMap, "privileges", "privilege")
+	if err != nil {
+		return
+	}
+	err = NormalizeAlias(&yamlMap, "schemas", "schema")
+	if err != nil {
+		return
+	}
+	err = NormalizeAlias(&yamlMap, "roles", "role")
+	if err != nil {
+		return
+	}
+
+	maps.Copy(rule, yamlMap)
+
+	rule["privileges"], err = NormalizeStringList(rule["privileges"])
+	if err != nil {
+		return nil, fmt.Errorf("privileges: %w", err)
+	}
+	if len(rule["privileges"].([]string)) == 0 {
+		return nil, errors.New("missing privileges")
+	}
+
+	err = CheckIsString(rule["on"])
+	if err != nil {
+		return nil, fmt.Errorf("on: %w", err)
+	}
+	onType, ok := grantOnTypes[rule["on"].(string)]
+	if !ok {
+		return nil, fmt.Errorf("on: unknown value %v", rule["on"])
+	}
+	rule["on"] = onType
+
+	rule["schemas"], err = NormalizeStringList(rule["schemas"])
+	if err != nil {
+		return nil, fmt.Errorf("schemas: %w", err)
+	}
+	if len(rule["schemas"].([]string)) == 0 {
+		return nil, errors.New("missing schemas")
+	}
+
+	rule["roles"], err = NormalizeStringList(rule["roles"])
+	if err != nil {
+		return nil, fmt.Errorf("roles: %w", err)
+	}
+	if len(rule["roles"].([]string)) == 0 {
+		return nil, errors.New("missing role")
+	}
+
+	withGrantOption, ok := rule["with_grant_option"].(bool)
+	if !ok {
+		return nil, fmt.Errorf("with_grant_option: must be bool, got %v", rule["with_grant_option"])
+	}
+	rule["with_grant_option"] = withGrantOption
+
+	err = CheckIsString(rule["owner"])
+	if err != nil {
+		return nil, fmt.Errorf("owner: %w", err)
+	}
+
+	rule["when"], err = NormalizeWhen(rule["when"])
+	if err != nil {
+		return nil, fmt.Errorf("when: %w", err)
+	}
+
+	err = CheckSpuriousKeys(&rule, "privileges", "on", "schemas", "roles", "with_grant_option", "owner", "when")
+	return
+}
+
+// DuplicateGrantRules expands one grant rule into one rule per
+// schema/role/privilege combination, and drops it entirely if its `when:`
+// condition rejects entry. entry is nil while expanding a rule at config
+// load time, before any LDAP search ran: policy.Decide then defers and
+// keeps every combination, so the real gating happens once an entry is
+// known.
+func DuplicateGrantRules(yaml map[string]interface{}, entry *ldap.Entry) (rules []map[string]interface{}, err error) {
+	when, _ := yaml["when"].(map[string]interface{})
+	match, err := policy.Decide(when, entry)
+	if err != nil {
+		return nil, err
+	}
+	if !match {
+		return nil, nil
+	}
+
+	for _, schema := range yaml["schemas"].([]string) {
+		for _, role := range yaml["roles"].([]string) {
+			for _, privilege := range yaml["privileges"].([]string) {
+				rule := make(map[string]interface{})
+				for key, value := range yaml {
+					switch key {
+					case "schemas", "roles", "privileges":
+						continue
+					}
+					rule[key] = value
+				}
+				rule["schema"] = schema
+				rule["role"] = role
+				rule["privilege"] = privilege
+				rules = append(rules, rule)
+			}
+		}
+	}
 	return
 }
 
@@ -240,6 +467,8 @@ func NormalizeRoleRule(yaml interface{}) (rule map[string]interface{}, err error
 		"comment": "Managed by ldap2pg",
 		"options": "",
 		"parents": []string{},
+		"when":    nil,
+		"inherit": nil,
 	}
 
 	switch yaml.(type) {
@@ -275,17 +504,65 @@ func NormalizeRoleRule(yaml interface{}) (rule map[string]interface{}, err error
 		if err != nil {
 			return nil, fmt.Errorf("options: %w", err)
 		}
+		err = NormalizeRoleInherit(rule)
+		if err != nil {
+			return nil, fmt.Errorf("inherit: %w", err)
+		}
+		rule["when"], err = NormalizeWhen(rule["when"])
+		if err != nil {
+			return nil, fmt.Errorf("when: %w", err)
+		}
 	default:
 		return nil, fmt.Errorf("bad type: %T", yaml)
 	}
 
-	err = CheckSpuriousKeys(&rule, "names", "comment", "parents", "options")
+	err = CheckSpuriousKeys(&rule, "names", "comment", "parents", "options", "when", "inherit")
 	return
 }
 
-// Normalize one rule with a list of names to a list of rules with a single
-// name.
-func DuplicateRoleRules(yaml map[string]interface{}) (rules []map[string]interface{}) {
+// NormalizeRoleInherit applies the `inherit:` shorthand onto rule's already
+// normalized `options` map, so `inherit: false` and `options: NOINHERIT`
+// are equivalent. Leaves options untouched when inherit is unset.
+func NormalizeRoleInherit(rule map[string]interface{}) error {
+	inherit, isSet := rule["inherit"]
+	if !isSet || inherit == nil {
+		return nil
+	}
+
+	options := rule["options"].(map[string]interface{})
+	switch value := inherit.(type) {
+	case bool:
+		options["INHERIT"] = value
+	case string:
+		switch strings.ToLower(value) {
+		case "inherit":
+			options["INHERIT"] = true
+		case "noinherit":
+			options["INHERIT"] = false
+		default:
+			return fmt.Errorf(`must be true, false or "noinherit", got %q`, value)
+		}
+	default:
+		return fmt.Errorf(`must be true, false or "noinherit", got %v`, inherit)
+	}
+	return nil
+}
+
+// DuplicateRoleRules normalizes one rule with a list of names to a list of
+// rules with a single name, and drops it entirely if its `when:` condition
+// rejects entry. entry is nil while expanding a rule at config load time,
+// before any LDAP search ran: policy.Decide then defers and keeps every
+// name, so the real gating happens once an entry is known.
+func DuplicateRoleRules(yaml map[string]interface{}, entry *ldap.Entry) (rules []map[string]interface{}, err error) {
+	when, _ := yaml["when"].(map[string]interface{})
+	match, err := policy.Decide(when, entry)
+	if err != nil {
+		return nil, err
+	}
+	if !match {
+		return nil, nil
+	}
+
 	for _, name := range yaml["names"].([]string) {
 		rule := make(map[string]interface{})
 		rule["name"] = name
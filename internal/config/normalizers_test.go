@@ -0,0 +1,119 @@
+package config_test
+
+import (
+	"testing"
+
+	"github.com/dalibo/ldap2pg/internal/config"
+	"github.com/dalibo/ldap2pg/internal/ldap"
+	"github.com/stretchr/testify/suite"
+)
+
+type Suite struct {
+	suite.Suite
+}
+
+func Test(t *testing.T) {
+	suite.Run(t, new(Suite))
+}
+
+// A rule's when: is only evaluated against a real LDAP entry, which does
+// not exist yet while duplicating a rule's static name/schema/role lists at
+// config load time. DuplicateRoleRules and DuplicateGrantRules are called
+// here with a nil entry, same as NormalizeSyncItem, so both must still
+// duplicate every combination: the condition only prunes once an entry is
+// known.
+func (s *Suite) TestDuplicateRoleRulesKeepsRuleWithoutEntry() {
+	rule := map[string]interface{}{
+		"names":   []string{"alice", "bob"},
+		"comment": "Managed by ldap2pg",
+		"parents": []string{},
+		"when": map[string]interface{}{
+			"member_of": "cn=dba,ou=groups,dc=acme,dc=tld",
+		},
+	}
+
+	duplicated, err := config.DuplicateRoleRules(rule, nil)
+	s.NoError(err)
+	s.Len(duplicated, 2)
+}
+
+func (s *Suite) TestDuplicateGrantRulesKeepsRuleWithoutEntry() {
+	rule := map[string]interface{}{
+		"schemas":    []string{"public"},
+		"roles":      []string{"reader"},
+		"privileges": []string{"select"},
+		"on":         "schema",
+		"when": map[string]interface{}{
+			"member_of": "cn=dba,ou=groups,dc=acme,dc=tld",
+		},
+	}
+
+	duplicated, err := config.DuplicateGrantRules(rule, nil)
+	s.NoError(err)
+	s.Len(duplicated, 1)
+}
+
+// Once a real entry is available, a non-matching when: must suppress the
+// rule entirely instead of just being recorded and ignored.
+func (s *Suite) TestDuplicateRoleRulesSuppressesOnMismatch() {
+	rule := map[string]interface{}{
+		"names":   []string{"alice"},
+		"comment": "Managed by ldap2pg",
+		"parents": []string{},
+		"when": map[string]interface{}{
+			"member_of": "cn=dba,ou=groups,dc=acme,dc=tld",
+		},
+	}
+	entry := &ldap.Entry{
+		Attributes: map[string][]string{
+			"memberOf": {"cn=developers,ou=groups,dc=acme,dc=tld"},
+		},
+	}
+
+	duplicated, err := config.DuplicateRoleRules(rule, entry)
+	s.NoError(err)
+	s.Empty(duplicated)
+}
+
+func (s *Suite) TestDuplicateGrantRulesKeepsOnMatch() {
+	rule := map[string]interface{}{
+		"schemas":    []string{"public"},
+		"roles":      []string{"reader"},
+		"privileges": []string{"select"},
+		"on":         "schema",
+		"when": map[string]interface{}{
+			"member_of": "cn=dba,ou=groups,dc=acme,dc=tld",
+		},
+	}
+	entry := &ldap.Entry{
+		Attributes: map[string][]string{
+			"memberOf": {"cn=dba,ou=groups,dc=acme,dc=tld"},
+		},
+	}
+
+	duplicated, err := config.DuplicateGrantRules(rule, entry)
+	s.NoError(err)
+	s.Len(duplicated, 1)
+}
+
+// A bad ldap_attr_matches regex must fail at normalization time (reached by
+// `config check`), not only once a real entry reaches policy.Evaluate, or
+// config check can't catch it offline.
+func (s *Suite) TestNormalizeWhenRejectsInvalidRegex() {
+	_, err := config.NormalizeWhen(map[string]interface{}{
+		"ldap_attr_matches": map[string]interface{}{
+			"employeeType": "(unterminated",
+		},
+	})
+	s.Error(err)
+}
+
+func (s *Suite) TestNormalizeWhenAcceptsValidRegex() {
+	when, err := config.NormalizeWhen(map[string]interface{}{
+		"ldap_attr_matches": map[string]interface{}{
+			"employeeType": "^dba.*$",
+		},
+	})
+	s.NoError(err)
+	s.NotNil(when)
+}
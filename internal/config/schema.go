@@ -0,0 +1,193 @@
+package config
+
+import (
+	"bytes"
+	_ "embed"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/santhosh-tekuri/jsonschema/v5"
+	"gopkg.in/yaml.v3"
+)
+
+//go:embed schema.json
+var schemaJSON []byte
+
+// schema is the compiled form of schema.json, built once at package init so
+// Validate never pays the compilation cost.
+var schema = mustCompileSchema()
+
+func mustCompileSchema() *jsonschema.Schema {
+	const resource = "ldap2pg.json"
+
+	compiler := jsonschema.NewCompiler()
+	if err := compiler.AddResource(resource, bytes.NewReader(schemaJSON)); err != nil {
+		panic(fmt.Sprintf("embedded schema.json: %v", err))
+	}
+	compiled, err := compiler.Compile(resource)
+	if err != nil {
+		panic(fmt.Sprintf("embedded schema.json: %v", err))
+	}
+	return compiled
+}
+
+// SchemaError decorates a single JSON Schema validation failure with the
+// YAML line/column it came from, so users don't have to guess which of
+// several `roles:` blocks is at fault.
+type SchemaError struct {
+	Path   string
+	Line   int
+	Column int
+	reason string
+}
+
+func (e *SchemaError) Error() string {
+	if e.Line > 0 {
+		return fmt.Sprintf("%d:%d: %s: %s", e.Line, e.Column, e.Path, e.reason)
+	}
+	return fmt.Sprintf("%s: %s", e.Path, e.reason)
+}
+
+// SchemaErrors collects every leaf validation failure from one document.
+type SchemaErrors []*SchemaError
+
+func (errs SchemaErrors) Error() string {
+	messages := make([]string, len(errs))
+	for i, err := range errs {
+		messages[i] = err.Error()
+	}
+	return strings.Join(messages, "\n")
+}
+
+// ValidateSchema checks a parsed YAML document against the embedded JSON
+// Schema before normalization runs, so spurious keys and type mistakes are
+// reported with a file position instead of a bare Go type error.
+func ValidateSchema(root *yaml.Node) error {
+	var value interface{}
+	if err := root.Decode(&value); err != nil {
+		return err
+	}
+
+	err := schema.Validate(value)
+	if err == nil {
+		return nil
+	}
+
+	validationErr, ok := err.(*jsonschema.ValidationError)
+	if !ok {
+		return err
+	}
+
+	var errs SchemaErrors
+	for _, leaf := range flattenCauses(validationErr) {
+		line, column := locate(root, leaf.InstanceLocation)
+		errs = append(errs, &SchemaError{
+			Path:   leaf.InstanceLocation,
+			Line:   line,
+			Column: column,
+			reason: leaf.Message,
+		})
+	}
+	return errs
+}
+
+// flattenCauses walks a jsonschema.ValidationError tree down to its leaves:
+// the causes actually responsible for the failure, as opposed to the
+// generic "doesn't validate against schema" wrappers above them.
+func flattenCauses(err *jsonschema.ValidationError) []*jsonschema.ValidationError {
+	if len(err.Causes) == 0 {
+		return []*jsonschema.ValidationError{err}
+	}
+	var leaves []*jsonschema.ValidationError
+	for _, cause := range err.Causes {
+		leaves = append(leaves, flattenCauses(cause)...)
+	}
+	return leaves
+}
+
+// locate walks root following a JSON Pointer instance location (as
+// produced by jsonschema, e.g. "/sync_map/0/roles") and returns the
+// line/column of the node it points to, falling back to the position of the
+// deepest node it could resolve when the rest of the path can't be.
+func locate(root *yaml.Node, instanceLocation string) (line, column int) {
+	node := root
+	if node.Kind == yaml.DocumentNode {
+		node = node.Content[0]
+	}
+
+	segments := strings.Split(strings.Trim(instanceLocation, "/"), "/")
+	if len(segments) == 1 && segments[0] == "" {
+		return node.Line, node.Column
+	}
+
+	for _, segment := range segments {
+		switch node.Kind {
+		case yaml.MappingNode:
+			found := false
+			for i := 0; i < len(node.Content); i += 2 {
+				if node.Content[i].Value == segment {
+					node = node.Content[i+1]
+					found = true
+					break
+				}
+			}
+			if !found {
+				return node.Line, node.Column
+			}
+		case yaml.SequenceNode:
+			index, convErr := strconv.Atoi(segment)
+			if convErr != nil || index >= len(node.Content) {
+				return node.Line, node.Column
+			}
+			node = node.Content[index]
+		default:
+			return node.Line, node.Column
+		}
+	}
+	return node.Line, node.Column
+}
+
+// LoadFile reads the YAML configuration file at path and runs it through
+// ValidateAndNormalize. It is the single entry point for turning a
+// configuration file into a normalized map: both the `config check`
+// subcommand (CheckFile) and a real synchronization run should load their
+// configuration through here instead of a plain os.ReadFile + yaml.Unmarshal,
+// so every run gets line/column annotated schema errors, not just checks.
+func LoadFile(path string) (normalized map[string]interface{}, err error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	normalized, err = ValidateAndNormalize(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return
+}
+
+// ValidateAndNormalize parses a YAML document, validates it against the
+// embedded JSON Schema with line/column annotated errors, and normalizes it.
+func ValidateAndNormalize(data []byte) (config map[string]interface{}, err error) {
+	var root yaml.Node
+	if err = yaml.Unmarshal(data, &root); err != nil {
+		return nil, fmt.Errorf("yaml: %w", err)
+	}
+	if len(root.Content) == 0 {
+		return nil, errors.New("empty configuration")
+	}
+
+	if err = ValidateSchema(&root); err != nil {
+		return nil, err
+	}
+
+	var raw interface{}
+	if err = root.Decode(&raw); err != nil {
+		return nil, fmt.Errorf("yaml: %w", err)
+	}
+
+	return NormalizeConfigRoot(raw)
+}
@@ -0,0 +1,37 @@
+package config_test
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/dalibo/ldap2pg/internal/config"
+)
+
+func (s *Suite) TestLoadFileRejectsSpuriousKey() {
+	path := filepath.Join(s.T().TempDir(), "ldap2pg.yml")
+	err := os.WriteFile(path, []byte("sync_map:\n- roles: {names: alice, bogus: true}\n"), 0o644)
+	s.Require().NoError(err)
+
+	_, err = config.LoadFile(path)
+	s.Error(err)
+}
+
+func (s *Suite) TestLoadFileAcceptsMinimalConfig() {
+	path := filepath.Join(s.T().TempDir(), "ldap2pg.yml")
+	err := os.WriteFile(path, []byte("sync_map:\n- roles: {names: alice}\n"), 0o644)
+	s.Require().NoError(err)
+
+	normalized, err := config.LoadFile(path)
+	s.NoError(err)
+	s.NotNil(normalized)
+}
+
+func (s *Suite) TestCheckFileDelegatesToLoadFile() {
+	path := filepath.Join(s.T().TempDir(), "ldap2pg.yml")
+	err := os.WriteFile(path, []byte("sync_map:\n- roles: {names: alice}\n"), 0o644)
+	s.Require().NoError(err)
+
+	normalized, err := config.CheckFile(path)
+	s.NoError(err)
+	s.NotNil(normalized)
+}
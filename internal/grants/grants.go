@@ -0,0 +1,182 @@
+// Schema-scoped ACL synchronization: GRANT/REVOKE on schemas, tables,
+// sequences and functions, including default privileges.
+package grants
+
+import (
+	"fmt"
+
+	"github.com/dalibo/ldap2pg/internal/postgres"
+	"github.com/jackc/pgx/v5"
+)
+
+// Grant is a single, fully resolved privilege: one privilege on one object
+// (or all objects of a type in a schema) granted to one role in one
+// database.
+type Grant struct {
+	Database        string
+	Schema          string
+	Object          string // Empty means every object of On in Schema.
+	On              string // SCHEMA, TABLE, SEQUENCE or FUNCTION.
+	Privilege       string
+	Grantee         string
+	Owner           string // Grantor role for ALTER DEFAULT PRIVILEGES. Empty for a plain grant.
+	WithGrantOption bool
+}
+
+type GrantSet map[string]Grant
+
+// Key identifies a grant regardless of whether it is currently held or
+// merely wanted, so current and wanted sets can be compared by key.
+func (g *Grant) Key() string {
+	return fmt.Sprintf("%s/%s/%s/%s/%s/%s/%s/%t", g.Database, g.Owner, g.Schema, g.On, g.Object, g.Privilege, g.Grantee, g.WithGrantOption)
+}
+
+func NewGrantFromRow(row pgx.CollectableRow) (grant Grant, err error) {
+	err = row.Scan(
+		&grant.Database, &grant.Schema, &grant.On, &grant.Object,
+		&grant.Privilege, &grant.Grantee, &grant.Owner, &grant.WithGrantOption,
+	)
+	return
+}
+
+// NewGrantFromRule builds a Grant from one rule map as produced by
+// config.DuplicateGrantRules: "schema", "role" and "privilege" hold a
+// single value each (already expanded out of their plural list form),
+// "on" already holds the SQL object type, and "owner" carries the
+// optional ALTER DEFAULT PRIVILEGES grantor.
+func NewGrantFromRule(database string, rule map[string]interface{}) Grant {
+	return Grant{
+		Database:        database,
+		Schema:          rule["schema"].(string),
+		On:              rule["on"].(string),
+		Privilege:       rule["privilege"].(string),
+		Grantee:         rule["role"].(string),
+		Owner:           rule["owner"].(string),
+		WithGrantOption: rule["with_grant_option"].(bool),
+	}
+}
+
+// onClause renders the ON target of the GRANT/REVOKE statement, falling
+// back to the ALL-in-schema form when Object is unset.
+func (g *Grant) onClause() string {
+	schema := pgx.Identifier{g.Schema}.Sanitize()
+	switch g.On {
+	case "SCHEMA":
+		return "SCHEMA " + schema
+	case "TABLE":
+		if g.Object == "" {
+			return "ALL TABLES IN SCHEMA " + schema
+		}
+		return "TABLE " + pgx.Identifier{g.Schema, g.Object}.Sanitize()
+	case "SEQUENCE":
+		if g.Object == "" {
+			return "ALL SEQUENCES IN SCHEMA " + schema
+		}
+		return "SEQUENCE " + pgx.Identifier{g.Schema, g.Object}.Sanitize()
+	case "FUNCTION":
+		if g.Object == "" {
+			return "ALL FUNCTIONS IN SCHEMA " + schema
+		}
+		return "FUNCTION " + pgx.Identifier{g.Schema, g.Object}.Sanitize()
+	default:
+		return "SCHEMA " + schema
+	}
+}
+
+// defaultOnClause renders the plural object-type used by ALTER DEFAULT
+// PRIVILEGES (e.g. TABLES, SEQUENCES).
+func (g *Grant) defaultOnClause() string {
+	switch g.On {
+	case "TABLE":
+		return "TABLES"
+	case "SEQUENCE":
+		return "SEQUENCES"
+	case "FUNCTION":
+		return "FUNCTIONS"
+	default:
+		return "TABLES"
+	}
+}
+
+// Create emits the GRANT statement(s) needed to hold this grant. When Owner
+// is set, this is a default privilege: future objects created by Owner in
+// Schema automatically receive it.
+func (g *Grant) Create(ch chan postgres.SyncQuery) {
+	grantee := pgx.Identifier{g.Grantee}.Sanitize()
+	query := `GRANT ` + g.Privilege + ` ON ` + g.onClause() + ` TO ` + grantee
+	if g.WithGrantOption {
+		query += ` WITH GRANT OPTION`
+	}
+	query += `;`
+
+	if g.Owner != "" {
+		owner := pgx.Identifier{g.Owner}.Sanitize()
+		schema := pgx.Identifier{g.Schema}.Sanitize()
+		query = `ALTER DEFAULT PRIVILEGES FOR ROLE ` + owner + ` IN SCHEMA ` + schema +
+			` GRANT ` + g.Privilege + ` ON ` + g.defaultOnClause() + ` TO ` + grantee
+		if g.WithGrantOption {
+			query += ` WITH GRANT OPTION`
+		}
+		query += `;`
+	}
+
+	ch <- postgres.SyncQuery{
+		Description: "Grant privilege.",
+		LogArgs: []interface{}{
+			"privilege", g.Privilege,
+			"on", g.On,
+			"schema", g.Schema,
+			"object", g.Object,
+			"to", g.Grantee,
+			"database", g.Database,
+		},
+		Database: g.Database,
+		Query:    query,
+	}
+}
+
+// Revoke emits the REVOKE statement(s) needed to drop this grant.
+func (g *Grant) Revoke(ch chan postgres.SyncQuery) {
+	grantee := pgx.Identifier{g.Grantee}.Sanitize()
+	query := `REVOKE ` + g.Privilege + ` ON ` + g.onClause() + ` FROM ` + grantee + `;`
+
+	if g.Owner != "" {
+		owner := pgx.Identifier{g.Owner}.Sanitize()
+		schema := pgx.Identifier{g.Schema}.Sanitize()
+		query = `ALTER DEFAULT PRIVILEGES FOR ROLE ` + owner + ` IN SCHEMA ` + schema +
+			` REVOKE ` + g.Privilege + ` ON ` + g.defaultOnClause() + ` FROM ` + grantee + `;`
+	}
+
+	ch <- postgres.SyncQuery{
+		Description: "Revoke privilege.",
+		LogArgs: []interface{}{
+			"privilege", g.Privilege,
+			"on", g.On,
+			"schema", g.Schema,
+			"object", g.Object,
+			"from", g.Grantee,
+			"database", g.Database,
+		},
+		Database: g.Database,
+		Query:    query,
+	}
+}
+
+// Diff compares current and wanted grant sets and emits the REVOKE/GRANT
+// queries needed to reconcile the former into the latter.
+func Diff(current, wanted GrantSet, ch chan postgres.SyncQuery) {
+	for key, w := range wanted {
+		if _, ok := current[key]; ok {
+			continue
+		}
+		w := w
+		w.Create(ch)
+	}
+	for key, c := range current {
+		if _, ok := wanted[key]; ok {
+			continue
+		}
+		c := c
+		c.Revoke(ch)
+	}
+}
@@ -0,0 +1,127 @@
+package grants_test
+
+import (
+	"testing"
+
+	"github.com/dalibo/ldap2pg/internal/grants"
+	"github.com/dalibo/ldap2pg/internal/postgres"
+	"github.com/stretchr/testify/suite"
+)
+
+type Suite struct {
+	suite.Suite
+}
+
+func Test(t *testing.T) {
+	suite.Run(t, new(Suite))
+}
+
+func (s *Suite) TestCreatePlainGrant() {
+	g := grants.Grant{
+		Database:  "mydb",
+		Schema:    "public",
+		On:        "TABLE",
+		Privilege: "SELECT",
+		Grantee:   "reader",
+	}
+	ch := make(chan postgres.SyncQuery, 1)
+	g.Create(ch)
+	query := <-ch
+	s.Equal(`GRANT SELECT ON ALL TABLES IN SCHEMA "public" TO "reader";`, query.Query)
+	s.Equal("mydb", query.Database)
+}
+
+func (s *Suite) TestCreateWithGrantOption() {
+	g := grants.Grant{Schema: "public", On: "SCHEMA", Privilege: "USAGE", Grantee: "app", WithGrantOption: true}
+	ch := make(chan postgres.SyncQuery, 1)
+	g.Create(ch)
+	query := <-ch
+	s.Equal(`GRANT USAGE ON SCHEMA "public" TO "app" WITH GRANT OPTION;`, query.Query)
+}
+
+func (s *Suite) TestCreateDefaultPrivilege() {
+	g := grants.Grant{Schema: "public", On: "TABLE", Privilege: "SELECT", Grantee: "reader", Owner: "app"}
+	ch := make(chan postgres.SyncQuery, 1)
+	g.Create(ch)
+	query := <-ch
+	s.Equal(`ALTER DEFAULT PRIVILEGES FOR ROLE "app" IN SCHEMA "public" GRANT SELECT ON TABLES TO "reader";`, query.Query)
+}
+
+func (s *Suite) TestRevokeOnOneObject() {
+	g := grants.Grant{Schema: "public", On: "TABLE", Object: "accounts", Privilege: "SELECT", Grantee: "reader"}
+	ch := make(chan postgres.SyncQuery, 1)
+	g.Revoke(ch)
+	query := <-ch
+	s.Equal(`REVOKE SELECT ON TABLE "public"."accounts" FROM "reader";`, query.Query)
+}
+
+func (s *Suite) TestRevokeDefaultPrivilege() {
+	g := grants.Grant{Schema: "public", On: "SEQUENCE", Privilege: "USAGE", Grantee: "reader", Owner: "app"}
+	ch := make(chan postgres.SyncQuery, 1)
+	g.Revoke(ch)
+	query := <-ch
+	s.Equal(`ALTER DEFAULT PRIVILEGES FOR ROLE "app" IN SCHEMA "public" REVOKE USAGE ON SEQUENCES FROM "reader";`, query.Query)
+}
+
+func (s *Suite) TestNewGrantFromRule() {
+	rule := map[string]interface{}{
+		"schema":            "public",
+		"role":              "reader",
+		"privilege":         "SELECT",
+		"on":                "TABLE",
+		"owner":             "app",
+		"with_grant_option": false,
+	}
+	g := grants.NewGrantFromRule("mydb", rule)
+	s.Equal("mydb", g.Database)
+	s.Equal("app", g.Owner)
+	s.Equal("reader", g.Grantee)
+}
+
+func (s *Suite) TestDiff() {
+	current := grants.GrantSet{
+		"stale": {Schema: "public", On: "SCHEMA", Privilege: "USAGE", Grantee: "old"},
+	}
+	wanted := grants.GrantSet{
+		"fresh": {Schema: "public", On: "SCHEMA", Privilege: "USAGE", Grantee: "new"},
+	}
+
+	ch := make(chan postgres.SyncQuery, 8)
+	grants.Diff(current, wanted, ch)
+	close(ch)
+
+	var descriptions []string
+	for query := range ch {
+		descriptions = append(descriptions, query.Description)
+	}
+	s.ElementsMatch([]string{"Grant privilege.", "Revoke privilege."}, descriptions)
+}
+
+func (s *Suite) TestKeyDistinguishesWithGrantOption() {
+	plain := grants.Grant{Schema: "public", On: "SCHEMA", Privilege: "USAGE", Grantee: "reader"}
+	withOption := plain
+	withOption.WithGrantOption = true
+	s.NotEqual(plain.Key(), withOption.Key())
+}
+
+// A with_grant_option flip must round-trip through the grant's Key() so
+// Diff (keyed on Key()) revokes the old grant and creates the new one,
+// instead of treating them as already in sync.
+func (s *Suite) TestDiffReconcilesWithGrantOptionFlip() {
+	plain := grants.Grant{Schema: "public", On: "SCHEMA", Privilege: "USAGE", Grantee: "reader"}
+	withOption := plain
+	withOption.WithGrantOption = true
+
+	current := grants.GrantSet{plain.Key(): plain}
+	wanted := grants.GrantSet{withOption.Key(): withOption}
+
+	ch := make(chan postgres.SyncQuery, 8)
+	grants.Diff(current, wanted, ch)
+	close(ch)
+
+	var descriptions []string
+	for query := range ch {
+		descriptions = append(descriptions, query.Description)
+	}
+	s.ElementsMatch([]string{"Grant privilege.", "Revoke privilege."}, descriptions)
+}
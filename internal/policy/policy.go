@@ -0,0 +1,174 @@
+// Package policy evaluates the `when:` conditions normalized by
+// internal/config against an LDAP entry, gating whether a sync rule
+// expands for that entry. This gives operators a Ladon-style condition
+// layer over sync_map rules without duplicating whole items.
+package policy
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/dalibo/ldap2pg/internal/ldap"
+)
+
+// Condition decides whether a rule applies to entry.
+type Condition interface {
+	Evaluate(entry *ldap.Entry) (bool, error)
+}
+
+type attrEquals struct {
+	attr  string
+	value string
+}
+
+func (c attrEquals) Evaluate(entry *ldap.Entry) (bool, error) {
+	for _, value := range entry.Attributes[c.attr] {
+		if value == c.value {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+type attrMatches struct {
+	attr string
+	re   *regexp.Regexp
+}
+
+func (c attrMatches) Evaluate(entry *ldap.Entry) (bool, error) {
+	for _, value := range entry.Attributes[c.attr] {
+		if c.re.MatchString(value) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+type dnInSubtree struct {
+	base string
+}
+
+func (c dnInSubtree) Evaluate(entry *ldap.Entry) (bool, error) {
+	dn := strings.ToLower(entry.Dn)
+	base := strings.ToLower(c.base)
+	return dn == base || strings.HasSuffix(dn, ","+base), nil
+}
+
+type memberOf struct {
+	group string
+}
+
+func (c memberOf) Evaluate(entry *ldap.Entry) (bool, error) {
+	for _, value := range entry.Attributes["memberOf"] {
+		if strings.EqualFold(value, c.group) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+type and []Condition
+
+func (c and) Evaluate(entry *ldap.Entry) (bool, error) {
+	for _, condition := range c {
+		ok, err := condition.Evaluate(entry)
+		if err != nil || !ok {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+type or []Condition
+
+func (c or) Evaluate(entry *ldap.Entry) (bool, error) {
+	for _, condition := range c {
+		ok, err := condition.Evaluate(entry)
+		if err != nil || ok {
+			return ok, err
+		}
+	}
+	return false, nil
+}
+
+type not struct {
+	Condition
+}
+
+func (c not) Evaluate(entry *ldap.Entry) (bool, error) {
+	ok, err := c.Condition.Evaluate(entry)
+	return !ok, err
+}
+
+// Compile turns a normalized `when:` map, as produced by
+// config.NormalizeWhen, into a Condition tree. A nil map compiles to a nil
+// Condition: always matches.
+func Compile(when map[string]interface{}) (Condition, error) {
+	if when == nil {
+		return nil, nil
+	}
+
+	for key, value := range when {
+		switch key {
+		case "ldap_attr_equals":
+			for attr, v := range value.(map[string]interface{}) {
+				return attrEquals{attr: attr, value: fmt.Sprintf("%v", v)}, nil
+			}
+		case "ldap_attr_matches":
+			for attr, v := range value.(map[string]interface{}) {
+				re, err := regexp.Compile(fmt.Sprintf("%v", v))
+				if err != nil {
+					return nil, fmt.Errorf("ldap_attr_matches: %s: %w", attr, err)
+				}
+				return attrMatches{attr: attr, re: re}, nil
+			}
+		case "dn_in_subtree":
+			return dnInSubtree{base: value.(string)}, nil
+		case "member_of":
+			return memberOf{group: value.(string)}, nil
+		case "and":
+			return compileList[and](value.([]interface{}))
+		case "or":
+			return compileList[or](value.([]interface{}))
+		case "not":
+			inner, err := Compile(value.(map[string]interface{}))
+			if err != nil {
+				return nil, fmt.Errorf("not: %w", err)
+			}
+			return not{inner}, nil
+		default:
+			return nil, fmt.Errorf("unknown condition: %s", key)
+		}
+	}
+	return nil, fmt.Errorf("empty condition")
+}
+
+func compileList[T ~[]Condition](list []interface{}) (Condition, error) {
+	conditions := make(T, 0, len(list))
+	for i, raw := range list {
+		condition, err := Compile(raw.(map[string]interface{}))
+		if err != nil {
+			return nil, fmt.Errorf("[%d]: %w", i, err)
+		}
+		conditions = append(conditions, condition)
+	}
+	return conditions, nil
+}
+
+// Decide reports whether the rule gated by when applies to entry. A nil
+// when always matches, so rules without a `when:` block keep applying to
+// every entry as before this package existed. A nil entry means no LDAP
+// entry is available yet (e.g. while expanding a rule's static name list
+// at config load time, before any search ran), so Decide defers and
+// reports a match too: the real gating happens once an entry is known.
+func Decide(when map[string]interface{}, entry *ldap.Entry) (bool, error) {
+	if when == nil || entry == nil {
+		return true, nil
+	}
+	condition, err := Compile(when)
+	if err != nil {
+		return false, err
+	}
+	return condition.Evaluate(entry)
+}
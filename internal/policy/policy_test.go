@@ -0,0 +1,85 @@
+package policy_test
+
+import (
+	"testing"
+
+	"github.com/dalibo/ldap2pg/internal/ldap"
+	"github.com/dalibo/ldap2pg/internal/policy"
+	"github.com/stretchr/testify/suite"
+)
+
+type Suite struct {
+	suite.Suite
+}
+
+func Test(t *testing.T) {
+	suite.Run(t, new(Suite))
+}
+
+func (s *Suite) TestDecideNilWhenAlwaysMatches() {
+	match, err := policy.Decide(nil, &ldap.Entry{})
+	s.NoError(err)
+	s.True(match)
+}
+
+func (s *Suite) TestDecideNilEntryDefers() {
+	when := map[string]interface{}{
+		"member_of": "cn=dba,ou=groups,dc=acme,dc=tld",
+	}
+	match, err := policy.Decide(when, nil)
+	s.NoError(err)
+	s.True(match)
+}
+
+func (s *Suite) TestDecideAttrEqualsMatches() {
+	when := map[string]interface{}{
+		"ldap_attr_equals": map[string]interface{}{
+			"employeeType": "dba",
+		},
+	}
+	entry := &ldap.Entry{
+		Attributes: map[string][]string{
+			"employeeType": {"dba"},
+		},
+	}
+	match, err := policy.Decide(when, entry)
+	s.NoError(err)
+	s.True(match)
+}
+
+func (s *Suite) TestDecideAttrEqualsRejects() {
+	when := map[string]interface{}{
+		"ldap_attr_equals": map[string]interface{}{
+			"employeeType": "dba",
+		},
+	}
+	entry := &ldap.Entry{
+		Attributes: map[string][]string{
+			"employeeType": {"developer"},
+		},
+	}
+	match, err := policy.Decide(when, entry)
+	s.NoError(err)
+	s.False(match)
+}
+
+func (s *Suite) TestDecideNot() {
+	when := map[string]interface{}{
+		"not": map[string]interface{}{
+			"member_of": "cn=dba,ou=groups,dc=acme,dc=tld",
+		},
+	}
+	entry := &ldap.Entry{
+		Attributes: map[string][]string{
+			"memberOf": {"cn=dba,ou=groups,dc=acme,dc=tld"},
+		},
+	}
+	match, err := policy.Decide(when, entry)
+	s.NoError(err)
+	s.False(match)
+}
+
+func (s *Suite) TestCompileUnknownCondition() {
+	_, err := policy.Compile(map[string]interface{}{"bogus": true})
+	s.Error(err)
+}
@@ -1,10 +1,15 @@
 package roles
 
 import (
+	"fmt"
+	"strings"
+
 	"github.com/dalibo/ldap2pg/internal/config"
 	"github.com/dalibo/ldap2pg/internal/postgres"
 	"github.com/jackc/pgx/v5"
 	"github.com/lithammer/dedent"
+	"golang.org/x/exp/maps"
+	"golang.org/x/exp/slices"
 )
 
 type Role struct {
@@ -94,6 +99,148 @@ func (r *Role) Create(ch chan postgres.SyncQuery) {
 	}
 }
 
+// Diff emits CREATE/ALTER queries for every wanted role. order must list
+// every wanted role name with parents before children, as returned by
+// TopoSortRoles, so a role exists before anything can reference it.
+//
+// Diff deliberately does not also drop unwanted roles here: a DROP ROLE
+// must wait until every membership REVOKE naming that role as a parent has
+// run, so call DropUnwanted only after reconciling membership. See
+// DropUnwanted.
+func Diff(current, wanted RoleSet, order []string, ch chan postgres.SyncQuery) {
+	for _, name := range order {
+		w, ok := wanted[name]
+		if !ok {
+			continue
+		}
+		c, ok := current[name]
+		if !ok {
+			w.Create(ch)
+			continue
+		}
+		c.Alter(w, ch)
+	}
+}
+
+// DropUnwanted emits DROP queries for every current role no longer wanted.
+// Call this after membership has been reconciled (ReconcileMembership), so
+// a REVOKE naming a dropped role as parent always runs before its DROP
+// ROLE, instead of racing it in channel order.
+func DropUnwanted(current, wanted RoleSet, databases []string, ch chan postgres.SyncQuery) {
+	dropped := maps.Keys(current)
+	slices.Sort(dropped)
+	for _, name := range dropped {
+		if _, ok := wanted[name]; ok {
+			continue
+		}
+		role := current[name]
+		role.Drop(databases, ch)
+	}
+}
+
+// ReconcileMembership diffs the role's current parents against wanted's
+// and emits the GRANT/REVOKE statements needed to converge membership.
+func (r *Role) ReconcileMembership(wanted Role, ch chan postgres.SyncQuery) {
+	identifier := pgx.Identifier{r.Name}.Sanitize()
+
+	currentParents := make(map[string]bool, len(r.Parents))
+	for _, parent := range r.Parents {
+		currentParents[parent] = true
+	}
+	wantedParents := make(map[string]bool, len(wanted.Parents))
+	for _, parent := range wanted.Parents {
+		wantedParents[parent] = true
+	}
+
+	for _, parent := range wanted.Parents {
+		if currentParents[parent] {
+			continue
+		}
+		parentIdentifier := pgx.Identifier{parent}.Sanitize()
+		ch <- postgres.SyncQuery{
+			Description: "Grant membership.",
+			LogArgs: []interface{}{
+				"role", r.Name,
+				"parent", parent,
+			},
+			Query: `GRANT ` + parentIdentifier + ` TO ` + identifier + `;`,
+		}
+	}
+
+	for _, parent := range r.Parents {
+		if wantedParents[parent] {
+			continue
+		}
+		parentIdentifier := pgx.Identifier{parent}.Sanitize()
+		ch <- postgres.SyncQuery{
+			Description: "Revoke membership.",
+			LogArgs: []interface{}{
+				"role", r.Name,
+				"parent", parent,
+			},
+			Query: `REVOKE ` + parentIdentifier + ` FROM ` + identifier + `;`,
+		}
+	}
+}
+
+// CycleError reports a membership cycle found while ordering role creation,
+// so the sync driver can fail fast with a config error instead of
+// deadlocking on a GRANT that references a role never created.
+type CycleError struct {
+	Cycle []string
+}
+
+func (e *CycleError) Error() string {
+	return fmt.Sprintf("role membership cycle: %s", strings.Join(e.Cycle, " -> "))
+}
+
+// TopoSortRoles orders wanted roles so that every parent comes before its
+// children, so membership grants can be applied right after their roles
+// exist. A parent that is not itself in wanted is listed too, ahead of its
+// children. It fails with a CycleError if the wanted membership graph isn't
+// a DAG.
+func TopoSortRoles(wanted RoleSet) (order []string, err error) {
+	const (
+		visiting = iota + 1
+		visited
+	)
+	state := make(map[string]int, len(wanted))
+	var path []string
+
+	var visit func(name string) error
+	visit = func(name string) error {
+		switch state[name] {
+		case visited:
+			return nil
+		case visiting:
+			return &CycleError{Cycle: append(append([]string{}, path...), name)}
+		}
+
+		state[name] = visiting
+		path = append(path, name)
+		if role, ok := wanted[name]; ok {
+			for _, parent := range role.Parents {
+				if err := visit(parent); err != nil {
+					return err
+				}
+			}
+		}
+		path = path[:len(path)-1]
+		state[name] = visited
+		order = append(order, name)
+		return nil
+	}
+
+	names := maps.Keys(wanted)
+	slices.Sort(names)
+	for _, name := range names {
+		if err := visit(name); err != nil {
+			return nil, err
+		}
+	}
+	return
+}
+
 func (r *Role) Drop(databases []string, ch chan postgres.SyncQuery) {
 	identifier := pgx.Identifier{r.Name}.Sanitize()
 	ch <- postgres.SyncQuery{
@@ -125,4 +272,4 @@ func (r *Role) Drop(databases []string, ch chan postgres.SyncQuery) {
 		},
 		Query: `DROP ROLE ` + identifier + `;`,
 	}
-}
\ No newline at end of file
+}
@@ -0,0 +1,104 @@
+package roles_test
+
+import (
+	"testing"
+
+	"github.com/dalibo/ldap2pg/internal/postgres"
+	"github.com/dalibo/ldap2pg/internal/roles"
+	"github.com/stretchr/testify/suite"
+)
+
+type Suite struct {
+	suite.Suite
+}
+
+func Test(t *testing.T) {
+	suite.Run(t, new(Suite))
+}
+
+func (s *Suite) TestDiffCreatesMissingRoles() {
+	current := roles.RoleSet{}
+	wanted := roles.RoleSet{
+		"alice": {Name: "alice"},
+	}
+
+	ch := make(chan postgres.SyncQuery, 8)
+	roles.Diff(current, wanted, []string{"alice"}, ch)
+	close(ch)
+
+	var descriptions []string
+	for query := range ch {
+		descriptions = append(descriptions, query.Description)
+	}
+	s.Contains(descriptions, "Create role.")
+	s.Contains(descriptions, "Set role comment.")
+}
+
+// Alter only compares options, so an existing role whose options already
+// match gets no query at all, neither CREATE nor ALTER.
+func (s *Suite) TestDiffDoesNotRecreateExistingRoles() {
+	current := roles.RoleSet{"alice": {Name: "alice"}}
+	wanted := roles.RoleSet{"alice": {Name: "alice", Comment: "changed"}}
+
+	ch := make(chan postgres.SyncQuery, 8)
+	roles.Diff(current, wanted, []string{"alice"}, ch)
+	close(ch)
+
+	for query := range ch {
+		s.Fail("unexpected query", query.Description)
+	}
+}
+
+func (s *Suite) TestDropUnwantedDropsUnwantedRoles() {
+	current := roles.RoleSet{"alice": {Name: "alice"}}
+	wanted := roles.RoleSet{}
+
+	ch := make(chan postgres.SyncQuery, 8)
+	roles.DropUnwanted(current, wanted, []string{"mydb"}, ch)
+	close(ch)
+
+	var found bool
+	for query := range ch {
+		if query.Description == "Drop role." {
+			found = true
+		}
+	}
+	s.True(found)
+}
+
+func (s *Suite) TestTopoSortRolesOrdersParentsBeforeChildren() {
+	wanted := roles.RoleSet{
+		"child":       {Name: "child", Parents: []string{"parent"}},
+		"parent":      {Name: "parent", Parents: []string{"grandparent"}},
+		"grandparent": {Name: "grandparent"},
+	}
+
+	order, err := roles.TopoSortRoles(wanted)
+	s.NoError(err)
+	s.Equal([]string{"grandparent", "parent", "child"}, order)
+}
+
+func (s *Suite) TestTopoSortRolesListsParentOutsideWanted() {
+	wanted := roles.RoleSet{
+		"alice": {Name: "alice", Parents: []string{"readonly"}},
+	}
+
+	order, err := roles.TopoSortRoles(wanted)
+	s.NoError(err)
+	s.Equal([]string{"readonly", "alice"}, order)
+}
+
+func (s *Suite) TestTopoSortRolesDetectsCycle() {
+	wanted := roles.RoleSet{
+		"alice": {Name: "alice", Parents: []string{"bob"}},
+		"bob":   {Name: "bob", Parents: []string{"alice"}},
+	}
+
+	_, err := roles.TopoSortRoles(wanted)
+	s.Error(err)
+
+	var cycleErr *roles.CycleError
+	s.ErrorAs(err, &cycleErr)
+	s.Contains(cycleErr.Cycle, "alice")
+	s.Contains(cycleErr.Cycle, "bob")
+}
@@ -0,0 +1,176 @@
+// Package sync executes the queries computed by the roles and grants
+// packages against real Postgres databases.
+//
+// The execution mode is selected by the --dry-run, --check and --real
+// flags surfaced through ldap2pg.NewConfig(): dry-run only logs the
+// queries, check reports drift without touching anything (exiting
+// non-zero so CI can catch unexpected manual changes), and real applies
+// them.
+package sync
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/dalibo/ldap2pg/internal/postgres"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"golang.org/x/exp/slog"
+)
+
+// Mode controls whether Driver.Sync applies queries or merely reports them.
+type Mode int
+
+const (
+	// ModeApply executes queries for real. This is the default.
+	ModeApply Mode = iota
+	// ModeDryRun computes and logs queries without executing them.
+	ModeDryRun
+	// ModeCheck is like ModeDryRun but returns a DriftError when queries
+	// would run, for CI drift detection.
+	ModeCheck
+)
+
+func (m Mode) String() string {
+	switch m {
+	case ModeDryRun:
+		return "dry-run"
+	case ModeCheck:
+		return "check"
+	case ModeApply:
+		return "real"
+	default:
+		return "unknown"
+	}
+}
+
+// DriftError is returned by Driver.Sync in ModeCheck when the instance
+// drifted from the wanted state.
+type DriftError struct {
+	Count int
+}
+
+func (e *DriftError) Error() string {
+	return fmt.Sprintf("%d change(s) required to converge", e.Count)
+}
+
+// Summary counts what a Sync run did, for structured logging.
+type Summary struct {
+	Total      int
+	ByAction   map[string]int
+	ByDatabase map[string]int
+	Duration   time.Duration
+}
+
+// LogArgs flattens the summary into slog key/value pairs.
+func (s Summary) LogArgs() []interface{} {
+	return []interface{}{
+		"total", s.Total,
+		"by_action", s.ByAction,
+		"by_database", s.ByDatabase,
+		"duration", s.Duration,
+	}
+}
+
+// Driver holds one pgxpool.Pool per database, so queries can be applied in
+// per-database transactions without reconnecting.
+type Driver struct {
+	pools           map[string]*pgxpool.Pool
+	defaultDatabase string
+}
+
+// NewDriver opens one pool per database in dbMap, connecting with dsn as a
+// template and overriding only the database name.
+func NewDriver(ctx context.Context, dsn string, dbMap postgres.DBMap, defaultDatabase string) (driver *Driver, err error) {
+	baseConfig, err := pgxpool.ParseConfig(dsn)
+	if err != nil {
+		return nil, fmt.Errorf("dsn: %w", err)
+	}
+
+	driver = &Driver{
+		pools:           make(map[string]*pgxpool.Pool),
+		defaultDatabase: defaultDatabase,
+	}
+	for _, name := range dbMap.SyncOrder(defaultDatabase) {
+		poolConfig := baseConfig.Copy()
+		poolConfig.ConnConfig.Database = name
+		pool, poolErr := pgxpool.NewWithConfig(ctx, poolConfig)
+		if poolErr != nil {
+			driver.Close()
+			return nil, fmt.Errorf("database %s: %w", name, poolErr)
+		}
+		driver.pools[name] = pool
+	}
+	return
+}
+
+// Close releases every pool held by the driver.
+func (d *Driver) Close() {
+	for _, pool := range d.pools {
+		pool.Close()
+	}
+}
+
+// Sync drains queries, grouping them by database, then applies them
+// per-database in order according to mode. Each database's queries run in
+// a single transaction, rolled back on the first error.
+func (d *Driver) Sync(ctx context.Context, queries chan postgres.SyncQuery, order []string, mode Mode) (summary Summary, err error) {
+	start := time.Now()
+	summary.ByAction = make(map[string]int)
+	summary.ByDatabase = make(map[string]int)
+	grouped := make(map[string][]postgres.SyncQuery)
+
+	logLevel := slog.LevelDebug
+	if mode != ModeApply {
+		logLevel = slog.LevelInfo
+	}
+
+	for q := range queries {
+		database := q.Database
+		if database == "" {
+			database = d.defaultDatabase
+		}
+		grouped[database] = append(grouped[database], q)
+		summary.Total++
+		summary.ByAction[q.Description]++
+		summary.ByDatabase[database]++
+		slog.Log(ctx, logLevel, q.Description, q.LogArgs...)
+	}
+	summary.Duration = time.Since(start)
+
+	switch mode {
+	case ModeDryRun:
+		return summary, nil
+	case ModeCheck:
+		if summary.Total > 0 {
+			return summary, &DriftError{Count: summary.Total}
+		}
+		return summary, nil
+	}
+
+	for _, name := range order {
+		databaseQueries, ok := grouped[name]
+		if !ok {
+			continue
+		}
+		pool, ok := d.pools[name]
+		if !ok {
+			return summary, fmt.Errorf("database %s: no connection pool", name)
+		}
+
+		tx, txErr := pool.Begin(ctx)
+		if txErr != nil {
+			return summary, fmt.Errorf("database %s: begin: %w", name, txErr)
+		}
+		for _, q := range databaseQueries {
+			if _, execErr := tx.Exec(ctx, q.Query, q.QueryArgs...); execErr != nil {
+				_ = tx.Rollback(ctx)
+				return summary, fmt.Errorf("database %s: %s: %w", name, q.Description, execErr)
+			}
+		}
+		if commitErr := tx.Commit(ctx); commitErr != nil {
+			return summary, fmt.Errorf("database %s: commit: %w", name, commitErr)
+		}
+	}
+	return summary, nil
+}
@@ -0,0 +1,64 @@
+package sync
+
+import (
+	"context"
+	"testing"
+
+	"github.com/dalibo/ldap2pg/internal/postgres"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/stretchr/testify/suite"
+)
+
+type Suite struct {
+	suite.Suite
+}
+
+func Test(t *testing.T) {
+	suite.Run(t, new(Suite))
+}
+
+func (s *Suite) TestModeString() {
+	s.Equal("real", ModeApply.String())
+	s.Equal("dry-run", ModeDryRun.String())
+	s.Equal("check", ModeCheck.String())
+}
+
+func (s *Suite) TestDriftErrorMessage() {
+	err := &DriftError{Count: 3}
+	s.Equal("3 change(s) required to converge", err.Error())
+}
+
+func queries(items ...postgres.SyncQuery) chan postgres.SyncQuery {
+	ch := make(chan postgres.SyncQuery, len(items))
+	for _, item := range items {
+		ch <- item
+	}
+	close(ch)
+	return ch
+}
+
+// ModeDryRun and ModeCheck never touch a connection pool, so an empty
+// Driver exercises them without a live Postgres.
+func (s *Suite) TestSyncDryRunNeverErrors() {
+	driver := &Driver{pools: map[string]*pgxpool.Pool{}}
+	summary, err := driver.Sync(context.Background(), queries(postgres.SyncQuery{Description: "Create role."}), nil, ModeDryRun)
+	s.NoError(err)
+	s.Equal(1, summary.Total)
+}
+
+func (s *Suite) TestSyncCheckReturnsDriftErrorWhenQueriesPending() {
+	driver := &Driver{pools: map[string]*pgxpool.Pool{}}
+	summary, err := driver.Sync(context.Background(), queries(postgres.SyncQuery{Description: "Create role."}), nil, ModeCheck)
+
+	var driftErr *DriftError
+	s.ErrorAs(err, &driftErr)
+	s.Equal(1, driftErr.Count)
+	s.Equal(1, summary.Total)
+}
+
+func (s *Suite) TestSyncCheckReportsNoDriftWhenNothingPending() {
+	driver := &Driver{pools: map[string]*pgxpool.Pool{}}
+	summary, err := driver.Sync(context.Background(), queries(), nil, ModeCheck)
+	s.NoError(err)
+	s.Equal(0, summary.Total)
+}